@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apognu/gocal"
+)
+
+const (
+	// runConfigKeyIcsPrefix names an iCalendar (RFC 5545) source, either
+	// inline ICS text or an http(s) URL to poll, keyed by the suffix.
+	runConfigKeyIcsPrefix = "ics_"
+
+	// runConfigKeyIcsTTL overrides how long a parsed/fetched calendar is
+	// cached for before it is refreshed. Deliberately outside the `ics_`
+	// namespace so it can never collide with a calendar literally named
+	// `ttl` (i.e. `ics_ttl`).
+	runConfigKeyIcsTTL = "calendars_ttl"
+
+	defaultIcsTTL = time.Hour
+)
+
+// CalendarChecker resolves a named calendar (configured via `ics_<name>`)
+// to a boolean predicate for a given instant, e.g. "is today a holiday".
+// StrategyPlugin implements this so Rule can stay ignorant of how
+// calendars are fetched and cached.
+type CalendarChecker interface {
+	IsHoliday(name string, now time.Time) (bool, error)
+}
+
+// calendar caches the events parsed from one `ics_<name>` source, and
+// refreshes itself from source once ttl has elapsed.
+type calendar struct {
+	mu        sync.Mutex
+	source    string
+	ttl       time.Duration
+	fetchedAt time.Time
+	events    []gocal.Event
+}
+
+// refresh re-fetches and re-parses the calendar if its TTL has elapsed,
+// relative to now rather than time.Now(), matching the rest of the plugin's
+// convention of threading the evaluation instant through instead of
+// reading the wall clock directly, so holiday matching stays deterministic
+// and testable.
+func (c *calendar) refresh(now time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetchedAt.IsZero() && now.Sub(c.fetchedAt) < c.ttl {
+		return nil
+	}
+
+	var r io.Reader
+	if strings.HasPrefix(c.source, "http://") || strings.HasPrefix(c.source, "https://") {
+		resp, err := http.Get(c.source)
+		if err != nil {
+			return fmt.Errorf("fetching calendar: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("fetching calendar: unexpected status %s", resp.Status)
+		}
+		r = resp.Body
+	} else {
+		r = strings.NewReader(c.source)
+	}
+
+	// Expand recurring VEVENTs over a window around now, from one day
+	// before to one month after; isHolidayOn then matches instants against
+	// the expanded events.
+	start := now.AddDate(0, 0, -1)
+	end := now.AddDate(0, 1, 0)
+
+	parser := gocal.NewParser(r)
+	parser.Start, parser.End = &start, &end
+	if err := parser.Parse(); err != nil {
+		return fmt.Errorf("parsing calendar: %w", err)
+	}
+
+	c.events = parser.Events
+	c.fetchedAt = now
+	return nil
+}
+
+// isHolidayOn reports whether any VEVENT in the calendar covers the given
+// instant.
+func (c *calendar) isHolidayOn(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ev := range c.events {
+		if ev.Start == nil || ev.End == nil {
+			continue
+		}
+		if !now.Before(*ev.Start) && now.Before(*ev.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHoliday satisfies the CalendarChecker interface, looking up name
+// amongst the calendars configured via `ics_<name>` and refreshing it if
+// its TTL has elapsed.
+func (s *StrategyPlugin) IsHoliday(name string, now time.Time) (bool, error) {
+	s.calendarsMu.Lock()
+	cal, ok := s.calendars[name]
+	s.calendarsMu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("no calendar configured for %q", name)
+	}
+
+	if err := cal.refresh(now); err != nil {
+		return false, err
+	}
+	return cal.isHolidayOn(now), nil
+}
+
+// hasCalendar reports whether name refers to a configured `ics_<name>`
+// calendar, letting callers distinguish a calendar predicate reference
+// from an arbitrary expression.
+func (s *StrategyPlugin) hasCalendar(name string) bool {
+	s.calendarsMu.Lock()
+	defer s.calendarsMu.Unlock()
+	_, ok := s.calendars[name]
+	return ok
+}
+
+// registerCalendars adds any `ics_<name>` entries found in config to
+// s.calendars, called once per evaluation from calculateTargetCount. An
+// already-registered calendar whose source is unchanged keeps its cached,
+// TTL-refreshed events instead of being re-fetched on every tick, but still
+// picks up a new calendars_ttl value; one whose `ics_<name>` value changed
+// (new URL, new inline ICS text) is replaced outright, discarding its cache.
+func (s *StrategyPlugin) registerCalendars(config map[string]string) error {
+	ttl := defaultIcsTTL
+	if raw, ok := config[runConfigKeyIcsTTL]; ok {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid value for `%s`: %v", runConfigKeyIcsTTL, err)
+		}
+		ttl = d
+	}
+
+	s.calendarsMu.Lock()
+	defer s.calendarsMu.Unlock()
+
+	if s.calendars == nil {
+		s.calendars = make(map[string]*calendar)
+	}
+	for k, element := range config {
+		if !strings.HasPrefix(k, runConfigKeyIcsPrefix) {
+			continue
+		}
+		name := k[len(runConfigKeyIcsPrefix):]
+		if existing, ok := s.calendars[name]; ok && existing.source == element {
+			existing.mu.Lock()
+			existing.ttl = ttl
+			existing.mu.Unlock()
+			continue
+		}
+		s.calendars[name] = &calendar{source: element, ttl: ttl}
+	}
+	return nil
+}
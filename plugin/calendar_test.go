@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testICS = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//test//test//EN
+BEGIN:VEVENT
+UID:holiday-1@test
+DTSTAMP:20240101T000000Z
+DTSTART:20240101T000000Z
+DTEND:20240102T000000Z
+SUMMARY:New Year
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestIsHoliday_MatchesEventWindow(t *testing.T) {
+	s := newTestPlugin()
+	require.NoError(t, s.registerCalendars(map[string]string{
+		runConfigKeyIcsPrefix + "holidays": testICS,
+	}))
+
+	holiday, err := s.IsHoliday("holidays", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.True(t, holiday)
+
+	holiday, err = s.IsHoliday("holidays", time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.False(t, holiday)
+}
+
+// TestRegisterCalendars_ReplacesChangedSource pins the fix for a changed
+// `ics_<name>` config value being silently ignored once a calendar name was
+// already registered.
+func TestRegisterCalendars_ReplacesChangedSource(t *testing.T) {
+	s := newTestPlugin()
+	key := runConfigKeyIcsPrefix + "holidays"
+
+	require.NoError(t, s.registerCalendars(map[string]string{key: testICS}))
+	holiday, err := s.IsHoliday("holidays", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.True(t, holiday)
+
+	// The source changes to one with no events covering 2024-01-01.
+	require.NoError(t, s.registerCalendars(map[string]string{key: "BEGIN:VCALENDAR\nVERSION:2.0\nEND:VCALENDAR\n"}))
+	holiday, err = s.IsHoliday("holidays", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.False(t, holiday, "a changed ics_<name> source must replace the previously registered calendar")
+}
+
+// TestRegisterCalendars_TTLKeyDoesNotCollideWithNamedCalendar pins the fix
+// for the reserved TTL override key colliding with a calendar literally
+// named `ttl`.
+func TestRegisterCalendars_TTLKeyDoesNotCollideWithNamedCalendar(t *testing.T) {
+	s := newTestPlugin()
+	require.NoError(t, s.registerCalendars(map[string]string{
+		runConfigKeyIcsPrefix + "ttl": testICS,
+	}))
+	require.True(t, s.hasCalendar("ttl"), "a calendar literally named `ttl` must still be registered")
+}
+
+// TestRegisterCalendars_UpdatesTTLOnUnchangedSource pins the fix for a
+// changed calendars_ttl value having no effect on an already-registered
+// calendar whose ics_<name> source didn't also change.
+func TestRegisterCalendars_UpdatesTTLOnUnchangedSource(t *testing.T) {
+	s := newTestPlugin()
+	key := runConfigKeyIcsPrefix + "holidays"
+
+	require.NoError(t, s.registerCalendars(map[string]string{key: testICS, runConfigKeyIcsTTL: "1h"}))
+	require.NoError(t, s.registerCalendars(map[string]string{key: testICS, runConfigKeyIcsTTL: "2h"}))
+
+	s.calendarsMu.Lock()
+	cal := s.calendars["holidays"]
+	s.calendarsMu.Unlock()
+	require.Equal(t, 2*time.Hour, cal.ttl, "a new calendars_ttl must apply even when the calendar's source is unchanged")
+}
+
+func TestCalendarRefresh_UsesInjectedNow(t *testing.T) {
+	s := newTestPlugin()
+	require.NoError(t, s.registerCalendars(map[string]string{
+		runConfigKeyIcsPrefix + "holidays": testICS,
+		runConfigKeyIcsTTL:                 "1h",
+	}))
+
+	base := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	holiday, err := s.IsHoliday("holidays", base)
+	require.NoError(t, err)
+	require.False(t, holiday)
+
+	s.calendarsMu.Lock()
+	cal := s.calendars["holidays"]
+	s.calendarsMu.Unlock()
+	require.True(t, cal.fetchedAt.Equal(base), "refresh must stamp fetchedAt from the injected now, not time.Now()")
+}
@@ -0,0 +1,272 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/expr-lang/expr"
+
+	"github.com/hashicorp/nomad-autoscaler/sdk"
+)
+
+// evaluateExpressions resolves every `expression_<name>` entry (exprs maps
+// name to its source) to an int64, using github.com/expr-lang/expr.
+//
+// Each expression's environment exposes `count`, `metrics`, the time
+// helpers `now()`, `hour()`, `weekday()`, the metric aggregation helpers
+// `avg(metrics, window)`, `p95(metrics, window)`, `max(...)` and
+// `rate(metrics, window)` (window is a Go duration string such as "10m"),
+// and `expr("other_name")` to reference another expression in the same
+// config map. Expressions are resolved depth-first with memoization; a
+// cycle reached via `expr(...)`, directly or transitively, is reported as
+// an error rather than recursed into forever.
+//
+// An expression whose source is exactly the name of a configured ics_
+// calendar is resolved against that calendar's holiday predicate instead
+// of being compiled, so `expression_isholiday = "holidays_us"` keeps
+// working the way request #4 set it up.
+//
+// Failed expressions are logged and simply absent from the returned map,
+// matching the pre-existing best-effort behaviour of this pass.
+func (s *StrategyPlugin) evaluateExpressions(exprs map[string]string, count int64, metrics sdk.TimestampedMetrics, now time.Time) map[string]int64 {
+	r := &expressionResolver{
+		plugin:   s,
+		exprs:    exprs,
+		count:    count,
+		metrics:  metrics,
+		now:      now,
+		resolved: make(map[string]int64),
+		failed:   make(map[string]bool),
+		visiting: make(map[string]bool),
+	}
+
+	for name := range exprs {
+		if _, err := r.resolve(name); err != nil {
+			s.logger.Warn("could not evaluate expression", "expression", name, "error", err)
+		}
+	}
+	return r.resolved
+}
+
+type expressionResolver struct {
+	plugin  *StrategyPlugin
+	exprs   map[string]string
+	count   int64
+	metrics sdk.TimestampedMetrics
+	now     time.Time
+
+	resolved map[string]int64
+	failed   map[string]bool
+	visiting map[string]bool
+}
+
+// resolve evaluates (and memoizes) the expression named name, detecting
+// cycles introduced via `expr("name")` references.
+func (r *expressionResolver) resolve(name string) (int64, error) {
+	if v, ok := r.resolved[name]; ok {
+		return v, nil
+	}
+	if r.failed[name] {
+		return 0, fmt.Errorf("expression %q already failed to evaluate", name)
+	}
+	if r.visiting[name] {
+		return 0, fmt.Errorf("cycle detected while evaluating expression %q", name)
+	}
+
+	src, ok := r.exprs[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown expression %q", name)
+	}
+
+	r.visiting[name] = true
+	val, err := r.eval(src)
+	delete(r.visiting, name)
+	if err != nil {
+		r.failed[name] = true
+		return 0, err
+	}
+
+	r.resolved[name] = val
+	return val, nil
+}
+
+func (r *expressionResolver) eval(src string) (int64, error) {
+	if calName := strings.TrimSpace(src); r.plugin.hasCalendar(calName) {
+		holiday, err := r.plugin.IsHoliday(calName, r.now)
+		if err != nil {
+			return 0, err
+		}
+		if holiday {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	env := map[string]interface{}{
+		"count":   r.count,
+		"metrics": r.metrics,
+		"now":     func() time.Time { return r.now },
+		"hour":    func() int { return r.now.Hour() },
+		"weekday": func() int { return int(r.now.Weekday()) },
+		"ceil":    math.Ceil,
+		"floor":   math.Floor,
+		"avg": func(m sdk.TimestampedMetrics, window string) (float64, error) {
+			return aggregateMetrics(m, window, r.now, avgAggregate)
+		},
+		"p95": func(m sdk.TimestampedMetrics, window string) (float64, error) {
+			return aggregateMetrics(m, window, r.now, p95Aggregate)
+		},
+		"rate": func(m sdk.TimestampedMetrics, window string) (float64, error) {
+			return rateOverWindow(m, window, r.now)
+		},
+		"max": maxValues,
+		"expr": func(name string) (float64, error) {
+			v, err := r.resolve(name)
+			return float64(v), err
+		},
+	}
+
+	program, err := expr.Compile(src, expr.Env(env))
+	if err != nil {
+		return 0, fmt.Errorf("compiling expression %q: %w", src, err)
+	}
+
+	output, err := expr.Run(program, env)
+	if err != nil {
+		return 0, fmt.Errorf("running expression %q: %w", src, err)
+	}
+
+	return toInt64(output)
+}
+
+// toInt64 converts an expr-lang result to the int64 the rest of the
+// plugin works with, rounding floats to the nearest integer.
+func toInt64(output interface{}) (int64, error) {
+	switch v := output.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		return int64(math.Round(v)), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("expression did not evaluate to a number: %T", output)
+	}
+}
+
+// maxValues implements the expression-language `max`, accepting either
+// scalar numbers (`max(4, 6)`) or a metrics collection (`max(metrics)`),
+// mirroring how a "cron" strategy author would want to cap a computed
+// target without reaching for two differently-named functions.
+func maxValues(args ...interface{}) (float64, error) {
+	var values []float64
+	for _, a := range args {
+		switch v := a.(type) {
+		case sdk.TimestampedMetrics:
+			for _, metric := range v {
+				values = append(values, metric.Value)
+			}
+		case int:
+			values = append(values, float64(v))
+		case int64:
+			values = append(values, float64(v))
+		case float64:
+			values = append(values, v)
+		default:
+			return 0, fmt.Errorf("max: unsupported argument type %T", a)
+		}
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("max: no values given")
+	}
+
+	m := values[0]
+	for _, v := range values[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m, nil
+}
+
+// aggregateMetrics filters m to the points within window of now, then
+// reduces them with agg.
+func aggregateMetrics(m sdk.TimestampedMetrics, window string, now time.Time, agg func([]float64) float64) (float64, error) {
+	dur, err := time.ParseDuration(window)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window %q: %w", window, err)
+	}
+	cutoff := now.Add(-dur)
+
+	var values []float64
+	for _, metric := range m {
+		if metric.Timestamp.Before(cutoff) {
+			continue
+		}
+		values = append(values, metric.Value)
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("no metrics in the last %s", window)
+	}
+	return agg(values), nil
+}
+
+func avgAggregate(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func p95Aggregate(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	idx := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// rateOverWindow returns the average per-minute change in value across
+// the metrics within window of now.
+func rateOverWindow(m sdk.TimestampedMetrics, window string, now time.Time) (float64, error) {
+	dur, err := time.ParseDuration(window)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window %q: %w", window, err)
+	}
+	cutoff := now.Add(-dur)
+
+	var filtered sdk.TimestampedMetrics
+	for _, metric := range m {
+		if metric.Timestamp.Before(cutoff) {
+			continue
+		}
+		filtered = append(filtered, metric)
+	}
+	if len(filtered) < 2 {
+		return 0, fmt.Errorf("rate: need at least 2 metrics in the last %s", window)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Timestamp.Before(filtered[j].Timestamp) })
+
+	first, last := filtered[0], filtered[len(filtered)-1]
+	elapsedMinutes := last.Timestamp.Sub(first.Timestamp).Minutes()
+	if elapsedMinutes <= 0 {
+		return 0, fmt.Errorf("rate: zero time span in the last %s", window)
+	}
+	return (last.Value - first.Value) / elapsedMinutes, nil
+}
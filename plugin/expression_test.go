@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp/nomad-autoscaler/sdk"
+)
+
+func TestEvaluateExpressions_Arithmetic(t *testing.T) {
+	s := newTestPlugin()
+	result := s.evaluateExpressions(map[string]string{
+		"double": "count * 2",
+	}, 5, nil, time.Now())
+	require.Equal(t, int64(10), result["double"])
+}
+
+func TestEvaluateExpressions_ReferencesAnotherExpression(t *testing.T) {
+	s := newTestPlugin()
+	result := s.evaluateExpressions(map[string]string{
+		"base":   "count + 1",
+		"scaled": `expr("base") * 2`,
+	}, 3, nil, time.Now())
+	require.Equal(t, int64(4), result["base"])
+	require.Equal(t, int64(8), result["scaled"])
+}
+
+// TestEvaluateExpressions_CycleDetected pins expression cycle detection: a
+// transitively self-referential expr("...") chain must not recurse forever,
+// and neither expression should end up resolved.
+func TestEvaluateExpressions_CycleDetected(t *testing.T) {
+	s := newTestPlugin()
+	result := s.evaluateExpressions(map[string]string{
+		"a": `expr("b") + 1`,
+		"b": `expr("a") + 1`,
+	}, 0, nil, time.Now())
+	_, aOK := result["a"]
+	_, bOK := result["b"]
+	require.False(t, aOK)
+	require.False(t, bOK)
+}
+
+func TestEvaluateExpressions_DirectSelfCycle(t *testing.T) {
+	s := newTestPlugin()
+	result := s.evaluateExpressions(map[string]string{
+		"a": `expr("a") + 1`,
+	}, 0, nil, time.Now())
+	_, ok := result["a"]
+	require.False(t, ok)
+}
+
+func TestAggregateMetrics_AvgAndP95(t *testing.T) {
+	now := time.Now()
+	metrics := sdk.TimestampedMetrics{
+		{Timestamp: now.Add(-3 * time.Minute), Value: 1},
+		{Timestamp: now.Add(-2 * time.Minute), Value: 2},
+		{Timestamp: now.Add(-1 * time.Minute), Value: 3},
+	}
+
+	avg, err := aggregateMetrics(metrics, "10m", now, avgAggregate)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, avg)
+
+	p95, err := aggregateMetrics(metrics, "10m", now, p95Aggregate)
+	require.NoError(t, err)
+	require.Equal(t, 3.0, p95)
+}
+
+func TestRateOverWindow(t *testing.T) {
+	now := time.Now()
+	metrics := sdk.TimestampedMetrics{
+		{Timestamp: now.Add(-10 * time.Minute), Value: 0},
+		{Timestamp: now, Value: 20},
+	}
+
+	rate, err := rateOverWindow(metrics, "15m", now)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, rate)
+}
@@ -0,0 +1,262 @@
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Rule represents a single `period_<name>` or `cron_<name>` config entry,
+// resolved to the count it activates and the priority used to pick a
+// winner when more than one rule is active for the same evaluation.
+type Rule struct {
+	period   string
+	priority int
+	count    int64
+
+	// start/end/hasWindow are populated for the classic `period_` format.
+	start, end time.Duration
+	hasWindow  bool
+
+	// schedule/duration are populated for the `cron_` format.
+	schedule cron.Schedule
+	duration time.Duration
+
+	// location is the timezone the rule is evaluated in: either the
+	// plugin-wide default, or a per-rule `;tz=` override.
+	location *time.Location
+
+	// calendarName/calendarNegate implement an optional `;if:<name>` or
+	// `;not:<name>` modifier, matching the rule against a named ics_
+	// calendar in addition to its time window.
+	calendarName   string
+	calendarNegate bool
+	checker        CalendarChecker
+}
+
+// RuleSorter sorts Rules by descending priority, so the most important
+// active rule always ends up at index 0.
+type RuleSorter []*Rule
+
+func (r RuleSorter) Len() int           { return len(r) }
+func (r RuleSorter) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r RuleSorter) Less(i, j int) bool {
+	if r[i].priority != r[j].priority {
+		return r[i].priority > r[j].priority
+	}
+	// A rule gated by a holiday/exception calendar is more specific than a
+	// plain time window, so it wins ties.
+	return r[i].calendarName != "" && r[j].calendarName == ""
+}
+
+// parsePeriodRule parses a single `period_` or `cron_` config entry into a
+// Rule, evaluated by default in defaultLocation.
+//
+// The `period_` family uses the classic "start<sep>end=count" format, e.g.
+// `period_weekday = "09:00->17:00=10"`. The `cron_` family accepts a
+// standard five-field cron expression, optionally with a leading
+// quartz-style seconds field, followed by `@` and a Go duration
+// describing how long the rule stays active once the expression matches,
+// e.g. `cron_business = "0 9 * * MON-FRI@8h=10"`. Either format accepts an
+// optional `;priority=N` suffix to break ties when several rules are
+// active at once (default 0), an optional `;tz=<IANA name>` suffix to
+// evaluate that single rule in a timezone other than the plugin default,
+// and an optional `;if:<name>` or `;not:<name>` suffix requiring a named
+// ics_ calendar to be (or not be) active alongside the time window, e.g.
+// `period_weekday = "09:00->17:00=10;not:holidays_us"`.
+func parsePeriodRule(key, value, separator string, defaultLocation *time.Location, checker CalendarChecker, expressionMap map[string]int64) (*Rule, error) {
+	isCron := strings.HasPrefix(key, runConfigKeyCronPrefix)
+
+	priority := 0
+	location := defaultLocation
+	calendarName := ""
+	calendarNegate := false
+	body := value
+	for {
+		idx := strings.LastIndex(body, ";priority=")
+		tzIdx := strings.LastIndex(body, ";tz=")
+		ifIdx := strings.LastIndex(body, ";if:")
+		notIdx := strings.LastIndex(body, ";not:")
+
+		cut := maxIndex(idx, tzIdx, ifIdx, notIdx)
+		if cut < 0 {
+			break
+		}
+
+		switch cut {
+		case idx:
+			p, err := strconv.Atoi(strings.TrimSpace(body[idx+len(";priority="):]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid priority for `%s`: %v", key, err)
+			}
+			priority = p
+		case tzIdx:
+			loc, err := time.LoadLocation(strings.TrimSpace(body[tzIdx+len(";tz="):]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid tz for `%s`: %v", key, err)
+			}
+			location = loc
+		case ifIdx:
+			calendarName = strings.TrimSpace(body[ifIdx+len(";if:"):])
+			calendarNegate = false
+		case notIdx:
+			calendarName = strings.TrimSpace(body[notIdx+len(";not:"):])
+			calendarNegate = true
+		}
+		body = body[:cut]
+	}
+
+	parts := strings.SplitN(body, "=", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid value for `%s`: %v, expected `<window>=<count>`", key, value)
+	}
+	window := strings.TrimSpace(parts[0])
+	countStr := strings.TrimSpace(parts[1])
+
+	count, err := strconv.ParseInt(countStr, 10, 64)
+	if err != nil {
+		exprValue, ok := expressionMap[countStr]
+		if !ok {
+			return nil, fmt.Errorf("invalid count for `%s`: %v", key, countStr)
+		}
+		count = exprValue
+	}
+
+	rule := &Rule{
+		period:         key,
+		priority:       priority,
+		count:          count,
+		location:       location,
+		calendarName:   calendarName,
+		calendarNegate: calendarNegate,
+		checker:        checker,
+	}
+
+	if isCron {
+		atIdx := strings.LastIndex(window, "@")
+		if atIdx < 0 {
+			return nil, fmt.Errorf("invalid cron window for `%s`: %v, expected `<cron expr>@<duration>`", key, window)
+		}
+
+		dur, err := time.ParseDuration(strings.TrimSpace(window[atIdx+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for `%s`: %v", key, err)
+		}
+
+		parser := cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		schedule, err := parser.Parse(strings.TrimSpace(window[:atIdx]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression for `%s`: %v", key, err)
+		}
+
+		rule.schedule = schedule
+		rule.duration = dur
+		return rule, nil
+	}
+
+	windowParts := strings.SplitN(window, separator, 2)
+	if len(windowParts) != 2 {
+		return nil, fmt.Errorf("invalid value for `%s`: %v, missing separator %q", key, window, separator)
+	}
+
+	start, err := parseTimeOfDay(strings.TrimSpace(windowParts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid start time for `%s`: %v", key, err)
+	}
+	end, err := parseTimeOfDay(strings.TrimSpace(windowParts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid end time for `%s`: %v", key, err)
+	}
+
+	rule.start = start
+	rule.end = end
+	rule.hasWindow = true
+	return rule, nil
+}
+
+// parseTimeOfDay parses an "HH:MM" clock time into the duration since
+// midnight it represents.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// InPeriod reports whether now falls inside the rule's active window,
+// evaluated in the rule's configured timezone, and satisfies its optional
+// calendar predicate, if any.
+func (r *Rule) InPeriod(now time.Time) bool {
+	if r.location != nil {
+		now = now.In(r.location)
+	}
+
+	var inWindow bool
+	if r.schedule != nil {
+		inWindow = r.inCronPeriod(now)
+	} else {
+		inWindow = r.inClockPeriod(now)
+	}
+	if !inWindow {
+		return false
+	}
+
+	if r.calendarName == "" {
+		return true
+	}
+
+	// A failed calendar lookup (e.g. misconfigured name) is treated as "not
+	// a match" rather than failing the whole evaluation.
+	holiday, err := r.checker.IsHoliday(r.calendarName, now)
+	if err != nil {
+		return false
+	}
+	if r.calendarNegate {
+		return !holiday
+	}
+	return holiday
+}
+
+// maxIndex returns the largest non-negative value amongst idx, or -1 if
+// all are negative (i.e. none of the modifiers matched).
+func maxIndex(idx ...int) int {
+	max := -1
+	for _, i := range idx {
+		if i > max {
+			max = i
+		}
+	}
+	return max
+}
+
+// inClockPeriod handles the classic `period_` start->end clock window,
+// including windows that wrap past midnight (e.g. 22:00->06:00).
+//
+// The offset is built from now's wall-clock fields rather than
+// now.Sub(midnight): on a DST transition day, midnight-to-now is not the
+// same number of hours as the wall clock reads, and start/end are always
+// meant as wall-clock times (e.g. "09:00" is 9am local regardless of
+// whether that day is 23, 24 or 25 hours long).
+func (r *Rule) inClockPeriod(now time.Time) bool {
+	offset := time.Duration(now.Hour())*time.Hour +
+		time.Duration(now.Minute())*time.Minute +
+		time.Duration(now.Second())*time.Second
+
+	if r.start <= r.end {
+		return offset >= r.start && offset < r.end
+	}
+	return offset >= r.start || offset < r.end
+}
+
+// inCronPeriod reports whether now falls within `duration` of the most
+// recent match of the rule's cron schedule. Schedules only look forward,
+// so we probe from one duration before now to find the match that would
+// have opened the window we might currently be inside.
+func (r *Rule) inCronPeriod(now time.Time) bool {
+	match := r.schedule.Next(now.Add(-r.duration))
+	return !match.After(now) && now.Before(match.Add(r.duration))
+}
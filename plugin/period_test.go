@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePeriodRule_CronWindow(t *testing.T) {
+	rule, err := parsePeriodRule("cron_business", "0 9 * * MON-FRI@8h=10", defaultSeparator, time.UTC, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, rule.schedule)
+	require.Equal(t, 8*time.Hour, rule.duration)
+	require.Equal(t, int64(10), rule.count)
+
+	// A Monday 09:00 UTC match should be active for the whole 8h window.
+	monday9am := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	require.True(t, rule.InPeriod(monday9am))
+	require.True(t, rule.InPeriod(monday9am.Add(7*time.Hour)))
+	require.False(t, rule.InPeriod(monday9am.Add(9*time.Hour)))
+
+	// Saturday never matches the MON-FRI schedule.
+	saturday := time.Date(2024, 1, 6, 9, 0, 0, 0, time.UTC)
+	require.False(t, rule.InPeriod(saturday))
+}
+
+func TestParsePeriodRule_ClockWindowWrapsMidnight(t *testing.T) {
+	rule, err := parsePeriodRule("period_night", "22:00->06:00=5", defaultSeparator, time.UTC, nil, nil)
+	require.NoError(t, err)
+
+	require.True(t, rule.InPeriod(time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)))
+	require.True(t, rule.InPeriod(time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)))
+	require.False(t, rule.InPeriod(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)))
+}
+
+// TestInClockPeriod_DSTSpringForward pins the fix for the DST bug in
+// inClockPeriod: a 09:00->17:00 window must use America/New_York's
+// wall-clock hours, not the physical elapsed time since midnight, even on
+// the day the clocks jump forward.
+func TestInClockPeriod_DSTSpringForward(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	rule, err := parsePeriodRule("period_business", "09:00->17:00=10", defaultSeparator, loc, nil, nil)
+	require.NoError(t, err)
+
+	// 2023-03-12 is a spring-forward day in America/New_York: 02:00 skips
+	// to 03:00, so the day is only 23 physical hours long.
+	wallClock930 := time.Date(2023, 3, 12, 9, 30, 0, 0, loc)
+	require.True(t, rule.InPeriod(wallClock930), "09:30 local must be inside a 09:00->17:00 window on a DST transition day")
+
+	wallClock8am := time.Date(2023, 3, 12, 8, 0, 0, 0, loc)
+	require.False(t, rule.InPeriod(wallClock8am))
+
+	wallClock6pm := time.Date(2023, 3, 12, 18, 0, 0, 0, loc)
+	require.False(t, rule.InPeriod(wallClock6pm))
+}
+
+// TestParsePeriodRule_TimezoneOverrideCombinesWithCalendarModifier pins
+// that a rule's `;tz=` override and `;not:`/`;if:` calendar modifier, both
+// introduced alongside each other, interact correctly in Rule.InPeriod: the
+// window is evaluated in the overridden timezone, and the calendar is
+// checked against that same timezone-adjusted instant.
+func TestParsePeriodRule_TimezoneOverrideCombinesWithCalendarModifier(t *testing.T) {
+	s := newTestPlugin()
+	require.NoError(t, s.registerCalendars(map[string]string{
+		runConfigKeyIcsPrefix + "holidays": testICS,
+	}))
+
+	rule, err := parsePeriodRule("period_business", "09:00->17:00=10;tz=America/New_York;not:holidays", defaultSeparator, time.UTC, s, nil)
+	require.NoError(t, err)
+
+	// 2024-01-01 12:00 UTC is 2024-01-01 07:00 America/New_York, so it is
+	// outside the 09:00->17:00 window even though the calendar would also
+	// reject it (New Year is in testICS).
+	require.False(t, rule.InPeriod(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)))
+
+	// 2024-01-01 14:00 UTC is 2024-01-01 09:00 America/New_York: inside the
+	// window, but still New Year's Day, so `;not:holidays` excludes it.
+	require.False(t, rule.InPeriod(time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)))
+
+	// 2024-01-02 14:00 UTC is 2024-01-02 09:00 America/New_York: inside the
+	// window and no longer a holiday, so the rule matches.
+	require.True(t, rule.InPeriod(time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)))
+}
+
+// TestRuleSorter_CalendarGatedWinsTie pins RuleSorter.Less's tie-break:
+// request #4 asks for holiday matching to participate in rule priority
+// sorting, so amongst two rules at the same priority, the one gated by a
+// calendar (`;if:`/`;not:`) must sort first.
+func TestRuleSorter_CalendarGatedWinsTie(t *testing.T) {
+	plain := &Rule{period: "period_plain", priority: 0, count: 5}
+	gated := &Rule{period: "period_gated", priority: 0, count: 9, calendarName: "holidays"}
+
+	rules := RuleSorter{plain, gated}
+	sort.Sort(rules)
+	require.Equal(t, "period_gated", rules[0].period, "a calendar-gated rule must win a priority tie over a plain one")
+
+	// Order in the input must not matter.
+	rules = RuleSorter{gated, plain}
+	sort.Sort(rules)
+	require.Equal(t, "period_gated", rules[0].period)
+}
@@ -5,6 +5,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
@@ -22,12 +23,16 @@ const (
 	defaultSeparator = "->"
 
 	configKeySeparator = "separator"
+	configKeyTimezone  = "timezone"
 
 	// These are the keys read from the RunRequest.Config map.
 	runConfigKeyCount            = "count"
 	runConfigKeyPeriodPrefix     = "period_"
+	runConfigKeyCronPrefix       = "cron_"
 	runConfigKeyExpressionPrefix = "expression_"
 	runConfigHysteresis          = "hysteresis"
+	runConfigKeyMaxScaleUp       = "max_scale_up"
+	runConfigKeyMaxScaleDown     = "max_scale_down"
 )
 
 var (
@@ -53,8 +58,15 @@ var _ strategy.Strategy = (*StrategyPlugin)(nil)
 // interface.
 type StrategyPlugin struct {
 	separator         string
+	location          *time.Location
 	logger            hclog.Logger
 	currentHysteresis int64
+
+	calendarsMu sync.Mutex
+	calendars   map[string]*calendar
+
+	stateStore  HysteresisStateStore
+	statePrefix string
 }
 
 // NewCronPlugin returns the Periods implementation of the
@@ -73,18 +85,27 @@ func (s *StrategyPlugin) PluginInfo() (*base.PluginInfo, error) {
 // SetConfig satisfies the SetConfig function on the base.Base interface.
 func (s *StrategyPlugin) SetConfig(config map[string]string) error {
 	s.separator = defaultSeparator
+	s.location = time.Local
 
 	sep, ok := config[configKeySeparator]
 	if ok {
 		s.separator = sep
 	}
 
-	return nil
+	if tz, ok := config[configKeyTimezone]; ok {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return fmt.Errorf("invalid value for `%s`: %v", configKeyTimezone, err)
+		}
+		s.location = loc
+	}
+
+	return s.configureStateStore(config)
 }
 
 // Run satisfies the Run function on the strategy.Strategy interface.
 func (s *StrategyPlugin) Run(eval *sdk.ScalingCheckEvaluation, count int64) (*sdk.ScalingCheckEvaluation, error) {
-	targetCount, err := s.calculateTargetCount(eval.Check.Strategy.Config, count, eval.Metrics, time.Now)
+	targetCount, err := s.calculateTargetCount(eval.Check.Strategy.Config, count, eval.Metrics, eval.Check.Name, time.Now)
 	if err != nil {
 		return eval, err
 	}
@@ -108,6 +129,19 @@ func (s *StrategyPlugin) Run(eval *sdk.ScalingCheckEvaluation, count int64) (*sd
 	return eval, nil
 }
 
+// clampRate caps how far value may move away from count in a single
+// evaluation, per the configured max_scale_up/max_scale_down (a negative
+// limit means "no limit" in that direction).
+func clampRate(value, count, maxScaleUp, maxScaleDown int64) int64 {
+	if maxScaleUp >= 0 && value > count && value-count > maxScaleUp {
+		value = count + maxScaleUp
+	}
+	if maxScaleDown >= 0 && value < count && count-value > maxScaleDown {
+		value = count - maxScaleDown
+	}
+	return value
+}
+
 // calculateDirection is used to calculate the direction of scaling that should
 // occur, if any at all.
 func (s *StrategyPlugin) calculateDirection(count, target int64) sdk.ScaleDirection {
@@ -119,27 +153,27 @@ func (s *StrategyPlugin) calculateDirection(count, target int64) sdk.ScaleDirect
 	return sdk.ScaleDirectionDown
 }
 
-func (s *StrategyPlugin) calculateTargetCount(config map[string]string, count int64, metrics sdk.TimestampedMetrics, timer func() time.Time) (int64, error) {
+func (s *StrategyPlugin) calculateTargetCount(config map[string]string, count int64, metrics sdk.TimestampedMetrics, checkName string, timer func() time.Time) (int64, error) {
 	now := timer()
 
 	var value int64 = 1
 	var rules []*Rule
 
-	expressionMap := make(map[string]int64)
+	if err := s.registerCalendars(config); err != nil {
+		return -1, err
+	}
+
 	// 1st pass, pick out the expressions
+	exprs := make(map[string]string)
 	for k, element := range config {
 		if strings.HasPrefix(k, runConfigKeyExpressionPrefix) && len(k) > len(runConfigKeyExpressionPrefix) {
-			exprName := k[len(runConfigKeyExpressionPrefix):]
-			val, err := evaluateExpression(element, count, metrics)
-			if err != nil {
-				s.logger.Warn("could not evaluate expression", "expression", element, "error", err)
-				continue
-			}
-			expressionMap[exprName] = val
+			exprs[k[len(runConfigKeyExpressionPrefix):]] = element
 		}
 	}
+	expressionMap := s.evaluateExpressions(exprs, count, metrics, now)
 
 	var hysteresis []int
+	var maxScaleUp, maxScaleDown int64 = -1, -1
 
 	for k, element := range config {
 		if k == runConfigKeyCount {
@@ -154,6 +188,25 @@ func (s *StrategyPlugin) calculateTargetCount(config map[string]string, count in
 			value = v
 		}
 
+		if k == runConfigKeyMaxScaleUp || k == runConfigKeyMaxScaleDown {
+			v, err := strconv.ParseInt(element, 10, 64)
+			if err != nil {
+				exprValue, ok := expressionMap[strings.TrimSpace(element)]
+				if !ok {
+					return -1, fmt.Errorf("invalid value for `%s`: %v (%T)", k, element, element)
+				}
+				v = exprValue
+			}
+			if v < 0 {
+				return -1, fmt.Errorf("invalid value for `%s`: %v, must not be negative", k, element)
+			}
+			if k == runConfigKeyMaxScaleUp {
+				maxScaleUp = v
+			} else {
+				maxScaleDown = v
+			}
+		}
+
 		if k == runConfigHysteresis {
 			// hysteresis definition.
 			// consists of a comma-separated list of at least 2 integers values
@@ -180,8 +233,8 @@ func (s *StrategyPlugin) calculateTargetCount(config map[string]string, count in
 			}
 		}
 
-		if strings.HasPrefix(k, runConfigKeyPeriodPrefix) {
-			rule, err := parsePeriodRule(k, element, s.separator, expressionMap)
+		if strings.HasPrefix(k, runConfigKeyPeriodPrefix) || strings.HasPrefix(k, runConfigKeyCronPrefix) {
+			rule, err := parsePeriodRule(k, element, s.separator, s.location, s, expressionMap)
 			if err != nil {
 				return -1, err
 			}
@@ -206,19 +259,47 @@ func (s *StrategyPlugin) calculateTargetCount(config map[string]string, count in
 		s.logger.Trace("selected period", "period", rules[0].period, "priority", rules[0].priority, "count", rules[0].count)
 		value = rules[0].count
 	}
-	if len(hysteresis) > 0 && value < count { // check for hysteresis only if the target value is smaller than the current count
-		// in which hysteresis bracket is the current count?
-		cIdx := sort.SearchInts(hysteresis, int(count))
-		if cIdx < len(hysteresis) && count == int64(hysteresis[cIdx]) {
-			// it is exact
-			cIdx++
+	// Rate-limit the delta before applying hysteresis. The sticky override
+	// below can still decide to restore an older, larger count, so the
+	// result is re-clamped afterwards too: max_scale_up/max_scale_down must
+	// cap every source of a value change, not just the period rules.
+	value = clampRate(value, count, maxScaleUp, maxScaleDown)
+
+	if len(hysteresis) > 0 {
+		// Use the bracket the strategy itself last committed to, not the
+		// raw current count, as the "which bracket am I in" proxy: the
+		// count can move for reasons unrelated to this strategy (min/max,
+		// capacity), and a persisted store survives agent restarts.
+		stateKey := s.statePrefix + checkName
+		sticky := count
+		if state, err := s.stateStore.Get(stateKey); err != nil {
+			s.logger.Warn("could not read hysteresis state", "key", stateKey, "error", err)
+		} else if state != nil {
+			sticky = state.Count
 		}
-		if cIdx > 0 {
-			lower := int64(hysteresis[cIdx-1])
-			if value > lower {
-				value = count
+
+		if value < sticky { // check for hysteresis only if the target value is smaller than the sticky bracket
+			// in which hysteresis bracket is the sticky value?
+			cIdx := sort.SearchInts(hysteresis, int(sticky))
+			if cIdx < len(hysteresis) && sticky == int64(hysteresis[cIdx]) {
+				// it is exact
+				cIdx++
+			}
+			if cIdx > 0 {
+				lower := int64(hysteresis[cIdx-1])
+				if value > lower {
+					value = sticky
+				}
 			}
 		}
+		// The sticky override above can restore a count from before the
+		// rate limit was applied, so re-clamp: max_scale_up/max_scale_down
+		// must cap every source of a value change, not just period rules.
+		value = clampRate(value, count, maxScaleUp, maxScaleDown)
+
+		if err := s.stateStore.Set(stateKey, &HysteresisState{Count: value, UpdatedAt: now}); err != nil {
+			s.logger.Warn("could not persist hysteresis state", "key", stateKey, "error", err)
+		}
 	}
 	return value, nil
 }
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hashicorp/nomad-autoscaler/sdk"
+)
+
+func newTestPlugin() *StrategyPlugin {
+	return &StrategyPlugin{
+		separator:   defaultSeparator,
+		location:    time.UTC,
+		logger:      hclog.NewNullLogger(),
+		stateStore:  newMemoryStateStore(),
+		statePrefix: defaultStatePrefix,
+	}
+}
+
+// TestSetConfig_TimezoneOverridesDefaultLocation pins that the plugin-wide
+// `timezone` config key is actually applied to s.location, rather than only
+// ever being exercised indirectly via parsePeriodRule in other tests.
+func TestSetConfig_TimezoneOverridesDefaultLocation(t *testing.T) {
+	s := &StrategyPlugin{logger: hclog.NewNullLogger()}
+
+	require.NoError(t, s.SetConfig(map[string]string{
+		configKeyTimezone: "America/New_York",
+	}))
+
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	require.Equal(t, loc, s.location)
+}
+
+func TestCalculateTargetCount_MaxScaleUpCapsIncrease(t *testing.T) {
+	s := newTestPlugin()
+
+	config := map[string]string{
+		"period_always":        "00:00->23:59=10",
+		runConfigKeyMaxScaleUp: "2",
+	}
+
+	value, err := s.calculateTargetCount(config, 3, nil, "check", time.Now)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), value, "increase from 3 towards 10 must not exceed max_scale_up=2")
+}
+
+func TestCalculateTargetCount_MaxScaleDownCapsDecrease(t *testing.T) {
+	s := newTestPlugin()
+
+	config := map[string]string{
+		"period_always":          "00:00->23:59=0",
+		runConfigKeyMaxScaleDown: "1",
+	}
+
+	value, err := s.calculateTargetCount(config, 5, nil, "check", time.Now)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), value, "decrease from 5 towards 0 must not exceed max_scale_down=1")
+}
+
+func TestCalculateTargetCount_PeriodRuleSelectsHighestPriority(t *testing.T) {
+	s := newTestPlugin()
+
+	config := map[string]string{
+		"period_low":  "00:00->23:59=5;priority=0",
+		"period_high": "00:00->23:59=9;priority=1",
+	}
+
+	value, err := s.calculateTargetCount(config, 5, sdk.TimestampedMetrics{}, "check", time.Now)
+	require.NoError(t, err)
+	require.Equal(t, int64(9), value)
+}
+
+func TestCalculateTargetCount_HysteresisStaysStickyWithinBracket(t *testing.T) {
+	s := newTestPlugin()
+
+	stateKey := s.statePrefix + "check"
+	require.NoError(t, s.stateStore.Set(stateKey, &HysteresisState{Count: 5, UpdatedAt: time.Now()}))
+
+	config := map[string]string{
+		"period_always":     "00:00->23:59=3",
+		runConfigHysteresis: "2,8",
+	}
+
+	// The sticky bracket is [2,8]; a drop to 3 doesn't cross below the
+	// lower bound (2), so the strategy should stick at the previously
+	// committed value (5) rather than following the rule down to 3.
+	value, err := s.calculateTargetCount(config, 5, nil, "check", time.Now)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), value)
+}
+
+// TestCalculateTargetCount_StickyOverrideReclamped pins the fix for the
+// hysteresis sticky override being able to reintroduce a jump bigger than
+// max_scale_up/max_scale_down: restoring a persisted sticky count must still
+// respect the configured rate limit, not just the period-rule value that
+// triggered the override.
+func TestCalculateTargetCount_StickyOverrideReclamped(t *testing.T) {
+	s := newTestPlugin()
+
+	stateKey := s.statePrefix + "check"
+	require.NoError(t, s.stateStore.Set(stateKey, &HysteresisState{Count: 6, UpdatedAt: time.Now()}))
+
+	config := map[string]string{
+		"period_always":        "00:00->23:59=3",
+		runConfigKeyMaxScaleUp: "4",
+		runConfigHysteresis:    "2,10",
+	}
+
+	value, err := s.calculateTargetCount(config, 0, nil, "check", time.Now)
+	require.NoError(t, err)
+	require.LessOrEqual(t, value, int64(4), "sticky override must be re-clamped to max_scale_up, not restore the raw sticky count verbatim")
+}
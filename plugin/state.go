@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+)
+
+const (
+	// configKeyStateBackend selects the HysteresisStateStore implementation:
+	// "memory" (default) or "consul".
+	configKeyStateBackend = "state_backend"
+
+	// configKeyStatePrefix namespaces the keys a HysteresisStateStore reads
+	// and writes under.
+	configKeyStatePrefix = "state_prefix"
+
+	// configKeyConsulAddress/configKeyConsulToken override the
+	// corresponding fields of consul.Config when state_backend is
+	// "consul"; unset, they fall back to consul.DefaultConfig()'s usual
+	// environment-variable behaviour (CONSUL_HTTP_ADDR, CONSUL_HTTP_TOKEN).
+	configKeyConsulAddress = "consul_address"
+	configKeyConsulToken   = "consul_token"
+
+	defaultStateBackend = "memory"
+	defaultStatePrefix  = "nomad-autoscaler/cron/"
+)
+
+// HysteresisState is the last bracket the strategy committed to for one
+// check, persisted so "sticky" behavior survives restarts and reflects
+// the strategy's own history rather than the raw, externally-influenced
+// current count.
+type HysteresisState struct {
+	Count     int64
+	UpdatedAt time.Time
+}
+
+// HysteresisStateStore persists and retrieves the last committed
+// HysteresisState for a given check key.
+type HysteresisStateStore interface {
+	Get(key string) (*HysteresisState, error)
+	Set(key string, state *HysteresisState) error
+}
+
+// memoryStateStore is the default HysteresisStateStore: it keeps state for
+// the lifetime of the plugin process only.
+type memoryStateStore struct {
+	mu     sync.Mutex
+	states map[string]*HysteresisState
+}
+
+func newMemoryStateStore() *memoryStateStore {
+	return &memoryStateStore{states: make(map[string]*HysteresisState)}
+}
+
+func (m *memoryStateStore) Get(key string) (*HysteresisState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states[key], nil
+}
+
+func (m *memoryStateStore) Set(key string, state *HysteresisState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[key] = state
+	return nil
+}
+
+// consulStateStore persists HysteresisState in Consul's KV store, so it
+// survives agent restarts and is shared across autoscaler instances.
+type consulStateStore struct {
+	client *consul.Client
+}
+
+// newConsulStateStore builds a consul.Client from consul.DefaultConfig(),
+// overridden by any configKeyConsulAddress/configKeyConsulToken entries in
+// config so a non-default Consul address/token can be set from the same
+// config map that carries state_backend/state_prefix.
+func newConsulStateStore(config map[string]string) (*consulStateStore, error) {
+	cfg := consul.DefaultConfig()
+	if addr, ok := config[configKeyConsulAddress]; ok {
+		cfg.Address = addr
+	}
+	if token, ok := config[configKeyConsulToken]; ok {
+		cfg.Token = token
+	}
+
+	client, err := consul.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating consul client: %w", err)
+	}
+	return &consulStateStore{client: client}, nil
+}
+
+func (c *consulStateStore) Get(key string) (*HysteresisState, error) {
+	pair, _, err := c.client.KV().Get(key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q from consul: %w", key, err)
+	}
+	if pair == nil {
+		return nil, nil
+	}
+
+	count, ts, err := decodeHysteresisState(pair.Value)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %q from consul: %w", key, err)
+	}
+	return &HysteresisState{Count: count, UpdatedAt: ts}, nil
+}
+
+func (c *consulStateStore) Set(key string, state *HysteresisState) error {
+	_, err := c.client.KV().Put(&consul.KVPair{
+		Key:   key,
+		Value: encodeHysteresisState(state),
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("writing %q to consul: %w", key, err)
+	}
+	return nil
+}
+
+// encodeHysteresisState/decodeHysteresisState use a tiny "<count>@<unix
+// nano>" text encoding: plenty for a single int64 and a timestamp, and
+// readable with `consul kv get` while debugging.
+func encodeHysteresisState(state *HysteresisState) []byte {
+	return []byte(fmt.Sprintf("%d@%d", state.Count, state.UpdatedAt.UnixNano()))
+}
+
+func decodeHysteresisState(raw []byte) (int64, time.Time, error) {
+	var count, nanos int64
+	if _, err := fmt.Sscanf(string(raw), "%d@%d", &count, &nanos); err != nil {
+		return 0, time.Time{}, err
+	}
+	return count, time.Unix(0, nanos), nil
+}
+
+// configureStateStore sets up s.stateStore and s.statePrefix from the
+// plugin-wide config, called from SetConfig.
+func (s *StrategyPlugin) configureStateStore(config map[string]string) error {
+	s.statePrefix = defaultStatePrefix
+	if prefix, ok := config[configKeyStatePrefix]; ok {
+		s.statePrefix = prefix
+	}
+
+	backend := defaultStateBackend
+	if b, ok := config[configKeyStateBackend]; ok {
+		backend = b
+	}
+
+	switch backend {
+	case "memory":
+		s.stateStore = newMemoryStateStore()
+	case "consul":
+		store, err := newConsulStateStore(config)
+		if err != nil {
+			return fmt.Errorf("invalid value for `%s`: %v", configKeyStateBackend, err)
+		}
+		s.stateStore = store
+	default:
+		return fmt.Errorf("invalid value for `%s`: %q, must be `memory` or `consul`", configKeyStateBackend, backend)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStateStore_SetGetRoundTrip(t *testing.T) {
+	store := newMemoryStateStore()
+
+	state, err := store.Get("missing")
+	require.NoError(t, err)
+	require.Nil(t, state)
+
+	want := &HysteresisState{Count: 7, UpdatedAt: time.Now()}
+	require.NoError(t, store.Set("check", want))
+
+	got, err := store.Get("check")
+	require.NoError(t, err)
+	require.Equal(t, want.Count, got.Count)
+}
+
+// TestConfigureStateStore_ConsulAcceptsAddressAndToken pins the fix for
+// newConsulStateStore ignoring the plugin config beyond the backend name:
+// a non-default Consul address/token must be accepted from config.
+func TestConfigureStateStore_ConsulAcceptsAddressAndToken(t *testing.T) {
+	s := newTestPlugin()
+	err := s.configureStateStore(map[string]string{
+		configKeyStateBackend:  "consul",
+		configKeyConsulAddress: "consul.internal:8500",
+		configKeyConsulToken:   "test-token",
+	})
+	require.NoError(t, err)
+
+	_, ok := s.stateStore.(*consulStateStore)
+	require.True(t, ok)
+}